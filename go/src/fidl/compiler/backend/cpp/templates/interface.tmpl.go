@@ -10,9 +10,9 @@ class {{ .Name }};
 using {{ .Name }}Ptr = ::fidl::InterfacePtr<{{ .Name }}>;
 class {{ .ProxyName }};
 class {{ .StubName }};
+class {{ .EventSenderName }};
 class {{ .SyncName }};
-// TODO(abarth): Add |SynchronousInterfacePtr|.
-// using {{ .Name }}SyncPtr = ::fidl::SynchronousInterfacePtr<{{ .Name }}>;
+using {{ .Name }}SyncPtr = ::fidl::SynchronousInterfacePtr<{{ .Name }}>;
 class {{ .SyncProxyName }};
 {{- end }}
 
@@ -34,6 +34,15 @@ class {{ .SyncProxyName }};
   {{- end -}}
 {{ end }}
 
+{{- define "ValidateMessage" -}}
+  const char* error_msg = nullptr;
+  zx_status_t status = message.Validate(&{{ . }}, &error_msg);
+  if (status != ZX_OK) {
+    FIDL_REPORT_ENCODING_ERROR(message, &{{ . }}, error_msg);
+    return;
+  }
+{{- end }}
+
 {{- define "RequestMethodSignature" -}}
   {{- if .HasResponse -}}
 {{ .Name }}({{ template "Params" .Request }}{{ if .Request }}, {{ end }}{{ .CallbackType }} callback)
@@ -43,7 +52,9 @@ class {{ .SyncProxyName }};
 {{ end -}}
 
 {{- define "SyncRequestMethodSignature" -}}
-  {{- if .Response -}}
+  {{- if .HasError -}}
+{{ .Name }}({{ template "Params" .Request }}{{ if .Request }}, {{ end }}::fit::result<{{ .Result.ValueDecl }}, {{ .Result.ErrorDecl }}>* out_result)
+  {{- else if .Response -}}
 {{ .Name }}({{ template "Params" .Request }}{{ if .Request }}, {{ end }}{{ template "OutParams" .Response }})
   {{- else -}}
 {{ .Name }}({{ template "Params" .Request }})
@@ -55,15 +66,90 @@ class {{ .Name }} {
  public:
   using Proxy_ = {{ .ProxyName }};
   using Stub_ = {{ .StubName }};
+  using EventSender_ = {{ .EventSenderName }};
   virtual ~{{ .Name }}();
 
+  {{- if .ServiceName }}
+  static constexpr char Name_[] = "{{ .ServiceName }}";
+
+  static zx_status_t ConnectAt(const char* service_directory_path, ::fidl::InterfaceHandle<{{ .Name }}>* out);
+  static zx_status_t Connect(::fidl::InterfaceHandle<{{ .Name }}>* out);
+  static {{ .Name }}Ptr ConnectAt(const char* service_directory_path);
+  static {{ .Name }}Ptr Connect();
+  {{- end }}
+
   {{- range .Methods }}
     {{- if .HasRequest }}
       {{- if .HasResponse }}
+        {{- if .HasError }}
+  using {{ .CallbackType }} =
+      std::function<void(::fit::result<{{ .Result.ValueDecl }}, {{ .Result.ErrorDecl }}>)>;
+        {{- else }}
   using {{ .CallbackType }} =
       std::function<void({{ template "ParamTypes" .Response }})>;
+        {{- end }}
       {{- end }}
   virtual void {{ template "RequestMethodSignature" . }} = 0;
+    {{- else }}
+      {{- if .HasResponse }}
+  using {{ .CallbackType }} =
+      std::function<void({{ template "ParamTypes" .Response }})>;
+      {{- end }}
+    {{- end }}
+  {{- end }}
+};
+
+class {{ .EventSenderName }} {
+ public:
+  explicit {{ .EventSenderName }}(::fidl::internal::WeakStubController* controller)
+      : controller_(controller) {}
+
+  {{- range .Methods }}
+    {{- if not .HasRequest }}
+      {{- if .HasResponse }}
+
+  void {{ .Name }}({{ template "Params" .Response }});
+      {{- end }}
+    {{- end }}
+  {{- end }}
+
+ private:
+  ::fidl::internal::WeakStubController* controller_;
+};
+
+class {{ .RequestEncoderName }} {
+ public:
+  static const fidl_type_t* GetType(uint64_t ordinal, bool* out_needs_response);
+
+  {{- range .Methods }}
+    {{- if .HasRequest }}
+  static ::fidl::Message {{ .Name }}(::fidl::Encoder* encoder{{ if .Request }}, {{ template "Params" .Request }}{{ end }});
+    {{- end }}
+  {{- end }}
+};
+
+class {{ .RequestDecoderName }} {
+ public:
+  static const fidl_type_t* GetType(uint64_t ordinal, bool* out_needs_response);
+
+  {{- range .Methods }}
+    {{- if .HasRequest }}
+      {{- if .Request }}
+  static void {{ .Name }}(::fidl::Decoder* decoder, {{ template "OutParams" .Request }});
+      {{- end }}
+    {{- end }}
+  {{- end }}
+};
+
+class {{ .ResponseDecoderName }} {
+ public:
+  static const fidl_type_t* GetType(uint64_t ordinal, bool* out_needs_response);
+
+  {{- range .Methods }}
+    {{- if .HasResponse }}
+      {{- if .Response }}
+  static void {{ .Name }}(::fidl::Decoder* decoder, {{ template "OutParams" .Response }});
+      {{- end }}
     {{- end }}
   {{- end }}
 };
@@ -85,6 +171,18 @@ class {{ .ProxyName }} : public {{ .Name }} {
   explicit {{ .ProxyName }}(::fidl::internal::ProxyController* controller);
   ~{{ .ProxyName }}() override;
 
+  struct EventHandlers_ {
+  {{- range .Methods }}
+    {{- if not .HasRequest }}
+      {{- if .HasResponse }}
+    {{ .CallbackType }} {{ .Name }};
+      {{- end }}
+    {{- end }}
+  {{- end }}
+  };
+
+  EventHandlers_& events() { return event_handlers_; }
+
   {{- range .Methods }}
     {{- if .HasRequest }}
   void {{ template "RequestMethodSignature" . }} override;
@@ -95,7 +193,10 @@ class {{ .ProxyName }} : public {{ .Name }} {
   {{ .ProxyName }}(const {{ .ProxyName }}&) = delete;
   {{ .ProxyName }}& operator=(const {{ .ProxyName }}&) = delete;
 
+  zx_status_t Dispatch_(::fidl::Message message);
+
   ::fidl::internal::ProxyController* controller_;
+  EventHandlers_ event_handlers_;
 };
 
 class {{ .StubName }} : public ::fidl::internal::Stub {
@@ -106,26 +207,39 @@ class {{ .StubName }} : public ::fidl::internal::Stub {
   zx_status_t Dispatch(::fidl::Message message,
                        ::fidl::internal::PendingResponse response) override;
 
+  {{ .EventSenderName }}& events() { return event_sender_; }
+
  private:
   {{ .Name }}* impl_;
+  {{ .EventSenderName }} event_sender_;
 };
 
 class {{ .SyncProxyName }} : public {{ .SyncName }} {
  public:
   explicit {{ .SyncProxyName }}(::zx::channel channel);
+  explicit {{ .SyncProxyName }}(::fidl::InterfaceHandle<{{ .Name }}> handle);
   ~{{ .SyncProxyName }}();
 
-  bool is_bound() const { return !!channel_; }
-  ::zx::channel TakeChannel_();
+  ::fidl::InterfaceRequest<{{ .Name }}> NewRequest();
+  void Bind(::zx::channel channel);
+  void Bind(::fidl::InterfaceHandle<{{ .Name }}> handle);
+  ::fidl::InterfaceHandle<{{ .Name }}> Unbind();
+
+  bool is_bound() const { return proxy_.is_bound(); }
+  const ::zx::channel& channel() const { return proxy_.channel(); }
 
   {{- range .Methods }}
     {{- if .HasRequest }}
   zx_status_t {{ template "SyncRequestMethodSignature" . }} override;
+      {{- if .HasResponse }}
+  zx_status_t {{ .Name }}({{ template "Params" .Request }}{{ if .Request }}, {{ end }}
+      {{- if .HasError }}::fit::result<{{ .Result.ValueDecl }}, {{ .Result.ErrorDecl }}>* out_result{{ else }}{{ template "OutParams" .Response }}{{ end }}{{ if or .HasError .Response }}, {{ end }}::zx::time deadline);
+      {{- end }}
     {{- end }}
   {{- end }}
 
-  private:
-  ::zx::channel channel_;
+ private:
+  ::fidl::internal::SynchronousProxy proxy_;
 };
 
 {{- end }}
@@ -133,22 +247,203 @@ class {{ .SyncProxyName }} : public {{ .SyncName }} {
 {{- define "InterfaceDefinition" }}
 namespace {
 {{ range .Methods }}
-  {{- if .HasRequest }}
 constexpr uint32_t {{ .OrdinalName }} = {{ .Ordinal }}u;
+  {{- if .HasRequest }}
+extern "C" const fidl_type_t {{ .RequestTypeTable }};
+  {{- end }}
+  {{- if .HasResponse }}
+extern "C" const fidl_type_t {{ .ResponseTypeTable }};
   {{- end }}
 {{- end }}
 
 }  // namespace
 
+const fidl_type_t* {{ .RequestEncoderName }}::GetType(uint64_t ordinal, bool* out_needs_response) {
+  switch (ordinal) {
+  {{- range .Methods }}
+    {{- if .HasRequest }}
+    case {{ .OrdinalName }}:
+      *out_needs_response = {{ if .HasResponse }}true{{ else }}false{{ end }};
+      return &{{ .RequestTypeTable }};
+    {{- end }}
+  {{- end }}
+    default:
+      return nullptr;
+  }
+}
+
+{{ range .Methods }}
+  {{- if .HasRequest }}
+::fidl::Message {{ $.RequestEncoderName }}::{{ .Name }}(::fidl::Encoder* encoder{{ if .Request }}, {{ template "Params" .Request }}{{ end }}) {
+    {{- if .Request }}
+  size_t offset = encoder->Alloc({{ .RequestSize }});
+      {{- range .Request }}
+  ::fidl::Encode(encoder, &{{ .Name }}, offset + {{ .Offset }});
+      {{- end }}
+    {{- end }}
+  return encoder->GetMessage();
+}
+  {{- end }}
+{{- end }}
+
+const fidl_type_t* {{ .RequestDecoderName }}::GetType(uint64_t ordinal, bool* out_needs_response) {
+  switch (ordinal) {
+  {{- range .Methods }}
+    {{- if .HasRequest }}
+    case {{ .OrdinalName }}:
+      *out_needs_response = {{ if .HasResponse }}true{{ else }}false{{ end }};
+      return &{{ .RequestTypeTable }};
+    {{- end }}
+  {{- end }}
+    default:
+      return nullptr;
+  }
+}
+
+{{ range .Methods }}
+  {{- if .HasRequest }}
+    {{- if .Request }}
+void {{ $.RequestDecoderName }}::{{ .Name }}(::fidl::Decoder* decoder, {{ template "OutParams" .Request }}) {
+  size_t offset = sizeof(fidl_message_header_t);
+    {{- range .Request }}
+  *out_{{ .Name }} = ::fidl::DecodeAs<{{ .Type.Decl }}>(decoder, offset + {{ .Offset }});
+    {{- end }}
+}
+    {{- end }}
+  {{- end }}
+{{- end }}
+
+const fidl_type_t* {{ .ResponseDecoderName }}::GetType(uint64_t ordinal, bool* out_needs_response) {
+  switch (ordinal) {
+  {{- range .Methods }}
+    {{- if .HasResponse }}
+    case {{ .OrdinalName }}:
+      *out_needs_response = false;
+      return &{{ .ResponseTypeTable }};
+    {{- end }}
+  {{- end }}
+    default:
+      return nullptr;
+  }
+}
+
+{{ range .Methods }}
+  {{- if .HasResponse }}
+    {{- if .Response }}
+void {{ $.ResponseDecoderName }}::{{ .Name }}(::fidl::Decoder* decoder, {{ template "OutParams" .Response }}) {
+  size_t offset = sizeof(fidl_message_header_t);
+    {{- range .Response }}
+  *out_{{ .Name }} = ::fidl::DecodeAs<{{ .Type.Decl }}>(decoder, offset + {{ .Offset }});
+    {{- end }}
+}
+    {{- end }}
+  {{- end }}
+{{- end }}
+
 {{ .Name }}::~{{ .Name }}() = default;
 
+{{- if .ServiceName }}
+constexpr char {{ .Name }}::Name_[];
+
+zx_status_t {{ .Name }}::ConnectAt(const char* service_directory_path, ::fidl::InterfaceHandle<{{ .Name }}>* out) {
+  ::zx::channel h1, h2;
+  zx_status_t status = ::zx::channel::create(0, &h1, &h2);
+  if (status != ZX_OK)
+    return status;
+  std::string path = std::string(service_directory_path) + "/" + {{ .Name }}::Name_;
+  status = fdio_service_connect(path.c_str(), h1.release());
+  if (status != ZX_OK)
+    return status;
+  *out = ::fidl::InterfaceHandle<{{ .Name }}>(std::move(h2));
+  return ZX_OK;
+}
+
+zx_status_t {{ .Name }}::Connect(::fidl::InterfaceHandle<{{ .Name }}>* out) {
+  return {{ .Name }}::ConnectAt("/svc", out);
+}
+
+{{ .Name }}Ptr {{ .Name }}::ConnectAt(const char* service_directory_path) {
+  ::fidl::InterfaceHandle<{{ .Name }}> handle;
+  {{ .Name }}::ConnectAt(service_directory_path, &handle);
+  {{ .Name }}Ptr ptr;
+  ptr.Bind(std::move(handle));
+  return ptr;
+}
+
+{{ .Name }}Ptr {{ .Name }}::Connect() {
+  return {{ .Name }}::ConnectAt("/svc");
+}
+{{- end }}
+
 {{ .SyncName }}::~{{ .SyncName }}() = default;
 
+{{ range .Methods }}
+  {{- if not .HasRequest }}
+    {{- if .HasResponse }}
+void {{ $.EventSenderName }}::{{ .Name }}({{ template "Params" .Response }}) {
+  ::fidl::Encoder encoder({{ .OrdinalName }});
+      {{- if .Response }}
+  size_t offset = encoder.Alloc({{ .ResponseSize }});
+        {{- range .Response }}
+  ::fidl::Encode(&encoder, &{{ .Name }}, offset + {{ .Offset }});
+        {{- end }}
+      {{- end }}
+  ::fidl::Message message = encoder.GetMessage();
+  {{ template "ValidateMessage" .ResponseTypeTable }}
+  controller_->Send(std::move(message));
+}
+    {{- end }}
+  {{- end }}
+{{- end }}
+
 {{ .ProxyName }}::{{ .ProxyName }}(::fidl::internal::ProxyController* controller)
-    : controller_(controller) {}
+    : controller_(controller) {
+  controller_->reader().set_message_handler(
+      [this](::fidl::Message message) { return Dispatch_(std::move(message)); });
+}
 
 {{ .ProxyName }}::~{{ .ProxyName }}() = default;
 
+zx_status_t {{ .ProxyName }}::Dispatch_(::fidl::Message message) {
+  zx_status_t status = ZX_OK;
+  switch (message.ordinal()) {
+  {{- range .Methods }}
+    {{- if not .HasRequest }}
+      {{- if .HasResponse }}
+    case {{ .OrdinalName }}: {
+      const char* error_msg = nullptr;
+      status = message.Decode(nullptr, &error_msg);
+      if (status != ZX_OK) {
+        fprintf(stderr, "error: fidl_decode: %s\n", error_msg);
+        break;
+      }
+        {{- if .Response }}
+      ::fidl::Decoder decoder(std::move(message));
+        {{- range .Response }}
+      {{ .Type.Decl }} {{ .Name }}{};
+        {{- end }}
+      {{ $.ResponseDecoderName }}::{{ .Name }}(&decoder{{ range .Response }}, &{{ .Name }}{{ end }});
+        {{- end }}
+      if (event_handlers_.{{ .Name }}) {
+        event_handlers_.{{ .Name }}(
+          {{- range $index, $param := .Response -}}
+            {{- if $index }}, {{ end }}std::move({{ .Name }})
+          {{- end -}}
+        );
+      }
+      break;
+    }
+      {{- end }}
+    {{- end }}
+  {{- end }}
+    default: {
+      status = ZX_ERR_NOT_SUPPORTED;
+      break;
+    }
+  }
+  return status;
+}
+
 {{ range .Methods }}
   {{- if .HasRequest }}
     {{- if .HasResponse }}
@@ -169,15 +464,24 @@ class {{ .ResponseHandlerType }} : public ::fidl::internal::MessageHandler {
       fprintf(stderr, "error: fidl_decode: %s\n", error_msg);
       return status;
     }
-      {{- if .Response }}
+      {{- if .HasError }}
     ::fidl::Decoder decoder(std::move(message));
-    size_t offset = sizeof(fidl_message_header_t);
+    callback_(::fidl::internal::DecodeResult<{{ .Result.ValueDecl }}, {{ .Result.ErrorDecl }}>(
+        &decoder, sizeof(fidl_message_header_t)));
+      {{- else }}
+        {{- if .Response }}
+    ::fidl::Decoder decoder(std::move(message));
+      {{- range .Response }}
+    {{ .Type.Decl }} {{ .Name }}{};
       {{- end }}
+    {{ $.ResponseDecoderName }}::{{ .Name }}(&decoder{{ range .Response }}, &{{ .Name }}{{ end }});
+        {{- end }}
     callback_(
       {{- range $index, $param := .Response -}}
-        {{- if $index }}, {{ end }}::fidl::DecodeAs<{{ .Type.Decl }}>(&decoder, offset + {{ .Offset }})
+        {{- if $index }}, {{ end }}std::move({{ .Name }})
       {{- end -}}
     );
+      {{- end }}
     return ZX_OK;
   }
 
@@ -193,22 +497,19 @@ class {{ .ResponseHandlerType }} : public ::fidl::internal::MessageHandler {
 {{- end }}
 void {{ $.ProxyName }}::{{ template "RequestMethodSignature" . }} {
   ::fidl::Encoder encoder({{ .OrdinalName }});
-    {{- if .Request }}
-  size_t offset = encoder.Alloc({{ .RequestSize }});
-      {{- range .Request }}
-  ::fidl::Encode(&encoder, &{{ .Name }}, offset + {{ .Offset }});
-      {{- end }}
-    {{- end }}
+  ::fidl::Message message = {{ $.RequestEncoderName }}::{{ .Name }}(&encoder{{ range .Request }}, std::move({{ .Name }}){{ end }});
+  {{ template "ValidateMessage" .RequestTypeTable }}
     {{- if .HasResponse }}
-  controller_->Send(nullptr, encoder.GetMessage(), std::make_unique<{{ .ResponseHandlerType }}>(std::move(callback)));
+  controller_->Send(&{{ .RequestTypeTable }}, std::move(message), std::make_unique<{{ .ResponseHandlerType }}>(std::move(callback)));
     {{- else }}
-  controller_->Send(nullptr, encoder.GetMessage(), nullptr);
+  controller_->Send(&{{ .RequestTypeTable }}, std::move(message), nullptr);
     {{- end }}
 }
   {{- end }}
 {{- end }}
 
-{{ .StubName }}::{{ .StubName }}({{ .Name }}* impl) : impl_(impl) {}
+{{ .StubName }}::{{ .StubName }}({{ .Name }}* impl)
+    : impl_(impl), event_sender_(weak_controller()) {}
 
 {{ .StubName }}::~{{ .StubName }}() = default;
 
@@ -222,6 +523,22 @@ class {{ .ResponderType }} {
  {{ .ResponderType }}(::fidl::internal::PendingResponse response)
       : response_(std::move(response)) {}
 
+    {{- if .HasError }}
+  void operator()(::fit::result<{{ .Result.ValueDecl }}, {{ .Result.ErrorDecl }}> result) {
+    ::fidl::Encoder encoder({{ .OrdinalName }});
+    size_t offset = encoder.Alloc({{ .ResponseSize }});
+    if (result.is_ok()) {
+      {{ .Result.ValueDecl }} value = result.take_value();
+      ::fidl::internal::EncodeResultValue(&encoder, &value, offset);
+    } else {
+      {{ .Result.ErrorDecl }} error = result.take_error();
+      ::fidl::internal::EncodeResultError(&encoder, &error, offset);
+    }
+    ::fidl::Message message = encoder.GetMessage();
+    {{ template "ValidateMessage" .ResponseTypeTable }}
+    response_.Send(&{{ .ResponseTypeTable }}, std::move(message));
+  }
+    {{- else }}
   void operator()({{ template "Params" .Response }}) {
     ::fidl::Encoder encoder({{ .OrdinalName }});
       {{- if .Response }}
@@ -230,8 +547,11 @@ class {{ .ResponderType }} {
   ::fidl::Encode(&encoder, &{{ .Name }}, offset + {{ .Offset }});
         {{- end }}
       {{- end }}
-    response_.Send(nullptr, encoder.GetMessage());
+    ::fidl::Message message = encoder.GetMessage();
+    {{ template "ValidateMessage" .ResponseTypeTable }}
+    response_.Send(&{{ .ResponseTypeTable }}, std::move(message));
   }
+    {{- end }}
 
  private:
   ::fidl::internal::PendingResponse response_;
@@ -258,11 +578,14 @@ zx_status_t {{ .StubName }}::Dispatch(
       }
         {{- if .Request }}
       ::fidl::Decoder decoder(std::move(message));
-      size_t offset = sizeof(fidl_message_header_t);
+          {{- range .Request }}
+      {{ .Type.Decl }} {{ .Name }}{};
+          {{- end }}
+      {{ $.RequestDecoderName }}::{{ .Name }}(&decoder{{ range .Request }}, &{{ .Name }}{{ end }});
         {{- end }}
       impl_->{{ .Name }}(
         {{- range $index, $param := .Request -}}
-          {{- if $index }}, {{ end }}::fidl::DecodeAs<{{ .Type.Decl }}>(&decoder, offset + {{ .Offset }})
+          {{- if $index }}, {{ end }}std::move({{ .Name }})
         {{- end -}}
         {{- if .HasResponse -}}
           {{- if .Request }}, {{ end -}}{{ .ResponderType }}(std::move(response))
@@ -281,49 +604,70 @@ zx_status_t {{ .StubName }}::Dispatch(
 }
 
 {{ .SyncProxyName }}::{{ .SyncProxyName }}(::zx::channel channel)
-  : channel_(::std::move(channel)) {}
+  : proxy_(::std::move(channel)) {}
+
+{{ .SyncProxyName }}::{{ .SyncProxyName }}(::fidl::InterfaceHandle<{{ .Name }}> handle)
+  : proxy_(handle.TakeChannel()) {}
 
 {{ .SyncProxyName }}::~{{ .SyncProxyName }}() = default;
 
-::zx::channel {{ .SyncProxyName }}::TakeChannel_() {
-  return std::move(channel_);
+::fidl::InterfaceRequest<{{ .Name }}> {{ .SyncProxyName }}::NewRequest() {
+  ::zx::channel h1, h2;
+  zx_status_t status = ::zx::channel::create(0, &h1, &h2);
+  if (status != ZX_OK)
+    return ::fidl::InterfaceRequest<{{ .Name }}>();
+  Bind(std::move(h1));
+  return ::fidl::InterfaceRequest<{{ .Name }}>(std::move(h2));
+}
+
+void {{ .SyncProxyName }}::Bind(::zx::channel channel) {
+  proxy_ = ::fidl::internal::SynchronousProxy(std::move(channel));
+}
+
+void {{ .SyncProxyName }}::Bind(::fidl::InterfaceHandle<{{ .Name }}> handle) {
+  Bind(handle.TakeChannel());
+}
+
+::fidl::InterfaceHandle<{{ .Name }}> {{ .SyncProxyName }}::Unbind() {
+  return ::fidl::InterfaceHandle<{{ .Name }}>(proxy_.TakeChannel());
 }
 
 {{- range .Methods }}
   {{- if .HasRequest }}
 zx_status_t {{ $.SyncProxyName }}::{{ template "SyncRequestMethodSignature" . }} {
+    {{- if .HasResponse }}
+  return {{ .Name }}(
+      {{- range .Request }}{{ .Name }}, {{ end -}}
+      {{- if .HasError }}out_result, {{ else }}{{ range .Response }}out_{{ .Name }}, {{ end }}{{ end -}}
+      ::zx::time::infinite());
+    {{- else }}
   ::fidl::Encoder encoder_({{ .OrdinalName }});
-    {{- if .Request }}
-  size_t offset_ = encoder_.Alloc({{ .RequestSize }});
-      {{- range .Request }}
-  ::fidl::Encode(&encoder_, &{{ .Name }}, offset_ + {{ .Offset }});
-      {{- end }}
+  ::fidl::Message message_ = {{ $.RequestEncoderName }}::{{ .Name }}(&encoder_{{ range .Request }}, std::move({{ .Name }}){{ end }});
+  return proxy_.Send(&{{ .RequestTypeTable }}, std::move(message_));
     {{- end }}
+}
     {{- if .HasResponse }}
+zx_status_t {{ $.SyncProxyName }}::{{ .Name }}({{ template "Params" .Request }}{{ if .Request }}, {{ end }}
+      {{- if .HasError }}::fit::result<{{ .Result.ValueDecl }}, {{ .Result.ErrorDecl }}>* out_result{{ else }}{{ template "OutParams" .Response }}{{ end }}{{ if or .HasError .Response }}, {{ end }}::zx::time deadline) {
+  ::fidl::Encoder encoder_({{ .OrdinalName }});
+  ::fidl::Message message_ = {{ $.RequestEncoderName }}::{{ .Name }}(&encoder_{{ range .Request }}, std::move({{ .Name }}){{ end }});
   ::fidl::MessageBuffer buffer_;
   ::fidl::Message response_ = buffer_.CreateEmptyMessage();
-  zx_status_t status = encoder_.GetMessage().Call(
-      channel_.get(), 0, ZX_TIME_INFINITE, nullptr, &response_);
+  zx_status_t status = proxy_.Call(&{{ .RequestTypeTable }}, &{{ .ResponseTypeTable }},
+                                    std::move(message_), &response_, deadline);
   if (status != ZX_OK)
     return status;
-  const char* error_msg = nullptr;
-  status = response_.Decode(nullptr, &error_msg);
-  if (status != ZX_OK) {
-    fprintf(stderr, "error: fidl_decode: %s\n", error_msg);
-    return status;
-  }
-      {{- if .Response }}
+      {{- if .HasError }}
   ::fidl::Decoder decoder_(std::move(response_));
-  offset_ = sizeof(fidl_message_header_t);
-        {{- range $index, $param := .Response }}
-  *out_{{ .Name }} = ::fidl::DecodeAs<{{ .Type.Decl }}>(&decoder_, offset_ + {{ .Offset }});
-        {{- end }}
+  *out_result = ::fidl::internal::DecodeResult<{{ .Result.ValueDecl }}, {{ .Result.ErrorDecl }}>(
+      &decoder_, sizeof(fidl_message_header_t));
+      {{- else if .Response }}
+  ::fidl::Decoder decoder_(std::move(response_));
+  {{ $.ResponseDecoderName }}::{{ .Name }}(&decoder_{{ range .Response }}, out_{{ .Name }}{{ end }});
       {{- end }}
   return ZX_OK;
-    {{- else }}
-  return encoder_.GetMessage().Write(channel_.get(), 0);
-    {{- end }}
 }
+    {{- end }}
   {{- end }}
 {{- end }}
 